@@ -0,0 +1,272 @@
+package ipld
+
+import (
+	"container/list"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// Axis identifies which root a leaf was fetched against.
+type Axis int
+
+const (
+	AxisRow Axis = iota
+	AxisCol
+)
+
+func (a Axis) String() string {
+	if a == AxisRow {
+		return "row"
+	}
+	return "col"
+}
+
+// AxisPicker decides, for a given pair of candidate roots, which one
+// Translate should resolve a leaf through, and is told the outcome
+// afterward so it can learn from it. Implementations must be safe for
+// concurrent use.
+type AxisPicker interface {
+	// Pick returns which of rowRoot or colRoot Translate should fetch
+	// through.
+	Pick(rowRoot, colRoot cid.Cid) Axis
+	// Observe records the result of fetching id (the root Pick chose)
+	// along axis, so future picks can take it into account.
+	Observe(axis Axis, id cid.Cid, latency time.Duration, err error)
+}
+
+// axisPickerMetrics holds picks/hits/misses counters per axis so operators
+// can see how an AxisPicker is steering fetches.
+type axisPickerMetrics struct {
+	picks, hits, misses [2]uint64 // indexed by Axis
+}
+
+func (m *axisPickerMetrics) recordPick(axis Axis) {
+	atomic.AddUint64(&m.picks[axis], 1)
+}
+
+func (m *axisPickerMetrics) recordOutcome(axis Axis, err error) {
+	if err != nil {
+		atomic.AddUint64(&m.misses[axis], 1)
+		return
+	}
+	atomic.AddUint64(&m.hits[axis], 1)
+}
+
+// AxisCounts is a picks/hits/misses snapshot for one axis.
+type AxisCounts struct {
+	Picks, Hits, Misses uint64
+}
+
+// Snapshot returns the current picks/hits/misses counts for row and column.
+func (m *axisPickerMetrics) Snapshot() (row, col AxisCounts) {
+	row = AxisCounts{
+		Picks:  atomic.LoadUint64(&m.picks[AxisRow]),
+		Hits:   atomic.LoadUint64(&m.hits[AxisRow]),
+		Misses: atomic.LoadUint64(&m.misses[AxisRow]),
+	}
+	col = AxisCounts{
+		Picks:  atomic.LoadUint64(&m.picks[AxisCol]),
+		Hits:   atomic.LoadUint64(&m.hits[AxisCol]),
+		Misses: atomic.LoadUint64(&m.misses[AxisCol]),
+	}
+	return row, col
+}
+
+// randomAxisPicker is the default AxisPicker and preserves the historical
+// behavior of Translate: an unweighted coin flip between row and column.
+type randomAxisPicker struct {
+	metrics axisPickerMetrics
+}
+
+// NewRandomAxisPicker returns the default AxisPicker, kept for backward
+// compatibility with Translate's pre-existing random behavior.
+func NewRandomAxisPicker() *randomAxisPicker {
+	return &randomAxisPicker{}
+}
+
+func (p *randomAxisPicker) Pick(cid.Cid, cid.Cid) Axis {
+	axis := AxisRow
+	if rand.Intn(2) == 0 { //nolint:gosec
+		axis = AxisCol
+	}
+	p.metrics.recordPick(axis)
+	return axis
+}
+
+func (p *randomAxisPicker) Observe(axis Axis, _ cid.Cid, _ time.Duration, err error) {
+	p.metrics.recordOutcome(axis, err)
+}
+
+// rootStats tracks the observed fetch latency and failure count for a single
+// row or column root.
+type rootStats struct {
+	n      int
+	avgLat float64 // seconds, running mean over successful fetches
+	fails  int
+}
+
+func (s *rootStats) observe(latency time.Duration, err error) {
+	if err != nil {
+		s.fails++
+		return
+	}
+	s.n++
+	s.avgLat += (latency.Seconds() - s.avgLat) / float64(s.n)
+}
+
+// snapshot returns a copy of s's fields, safe to score without p.mu held. A
+// nil receiver returns nil, matching score's own nil handling, since a root
+// not yet seen has no entry to copy.
+func (s *rootStats) snapshot() *rootStats {
+	if s == nil {
+		return nil
+	}
+	cp := *s
+	return &cp
+}
+
+// score is this root's UCB1 score: lower is better. A root with no attempts
+// at all (never observed, success or failure) scores -Inf so it is always
+// tried first (cold start). Failures add failurePenalty (10 seconds-equivalent)
+// per unit of failure rate, so a root that has only ever failed scores as a
+// 10-second mean latency, rather than being
+// mistaken for "never observed" and preferred forever: observe only
+// increments n on success, so gating cold-start on n alone would make an
+// axis that errors on every attempt look permanently best.
+func (s *rootStats) score(totalTries int) float64 {
+	if s == nil {
+		return math.Inf(-1)
+	}
+	attempts := s.n + s.fails
+	if attempts == 0 {
+		return math.Inf(-1)
+	}
+
+	const explorationConstant = 0.5
+	const failurePenalty = 10.0 // seconds-equivalent cost per unit of failure rate
+	exploration := explorationConstant * math.Sqrt(math.Log(float64(totalTries))/float64(attempts))
+	failureRate := float64(s.fails) / float64(attempts)
+	return s.avgLat + failurePenalty*failureRate - exploration
+}
+
+// rootStatsEntry is the value stored in UCB1AxisPicker's LRU.
+type rootStatsEntry struct {
+	id    cid.Cid
+	stats *rootStats
+}
+
+// UCB1AxisPicker biases Translate toward whichever candidate root has
+// historically been fetched faster and more reliably, using a UCB1-style
+// score (mean latency minus an exploration bonus that shrinks as a root is
+// tried more) so a consistently slow axis is starved without ever being
+// fully abandoned. When neither candidate has been seen (or both were evicted
+// from the LRU), or their scores tie, the pick is random between the two; a
+// candidate not yet seen is picked over one that has stats.
+type UCB1AxisPicker struct {
+	mu      sync.Mutex
+	entries map[cid.Cid]*list.Element
+	lru     *list.List // front = most recently used
+	maxSize int
+
+	metrics axisPickerMetrics
+}
+
+// NewUCB1AxisPicker returns a UCB1AxisPicker that remembers fetch outcomes
+// for up to maxSize distinct roots before evicting the least recently used.
+func NewUCB1AxisPicker(maxSize int) *UCB1AxisPicker {
+	return &UCB1AxisPicker{
+		entries: make(map[cid.Cid]*list.Element, maxSize),
+		lru:     list.New(),
+		maxSize: maxSize,
+	}
+}
+
+// Pick takes a snapshot of each candidate's stats under p.mu, then scores the
+// copies unlocked: score's own math runs unguarded, and scoring the live
+// *rootStats instead would race against a concurrent Observe call mutating
+// the same n/avgLat/fails fields.
+func (p *UCB1AxisPicker) Pick(rowRoot, colRoot cid.Cid) Axis {
+	p.mu.Lock()
+	rowStats := p.get(rowRoot).snapshot()
+	colStats := p.get(colRoot).snapshot()
+	p.mu.Unlock()
+
+	if rowStats == nil && colStats == nil {
+		return p.randomPick()
+	}
+
+	total := 1
+	if rowStats != nil {
+		total += rowStats.n + rowStats.fails
+	}
+	if colStats != nil {
+		total += colStats.n + colStats.fails
+	}
+
+	rowScore, colScore := rowStats.score(total), colStats.score(total)
+	switch {
+	case rowScore == colScore:
+		return p.randomPick()
+	case rowScore < colScore:
+		p.metrics.recordPick(AxisRow)
+		return AxisRow
+	default:
+		p.metrics.recordPick(AxisCol)
+		return AxisCol
+	}
+}
+
+func (p *UCB1AxisPicker) randomPick() Axis {
+	axis := AxisRow
+	if rand.Intn(2) == 0 { //nolint:gosec
+		axis = AxisCol
+	}
+	p.metrics.recordPick(axis)
+	return axis
+}
+
+func (p *UCB1AxisPicker) Observe(axis Axis, id cid.Cid, latency time.Duration, err error) {
+	p.metrics.recordOutcome(axis, err)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.getOrCreate(id).observe(latency, err)
+}
+
+// get returns the remembered stats for id without creating an entry,
+// bumping it to most-recently-used if present. Callers must hold p.mu.
+func (p *UCB1AxisPicker) get(id cid.Cid) *rootStats {
+	el, ok := p.entries[id]
+	if !ok {
+		return nil
+	}
+	p.lru.MoveToFront(el)
+	return el.Value.(*rootStatsEntry).stats
+}
+
+// getOrCreate returns the remembered stats for id, creating an entry and
+// evicting the least recently used one past maxSize if needed. Callers must
+// hold p.mu.
+func (p *UCB1AxisPicker) getOrCreate(id cid.Cid) *rootStats {
+	if el, ok := p.entries[id]; ok {
+		p.lru.MoveToFront(el)
+		return el.Value.(*rootStatsEntry).stats
+	}
+
+	stats := &rootStats{}
+	el := p.lru.PushFront(&rootStatsEntry{id: id, stats: stats})
+	p.entries[id] = el
+
+	if p.maxSize > 0 && p.lru.Len() > p.maxSize {
+		oldest := p.lru.Back()
+		p.lru.Remove(oldest)
+		delete(p.entries, oldest.Value.(*rootStatsEntry).id)
+	}
+
+	return stats
+}
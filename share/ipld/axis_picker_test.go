@@ -0,0 +1,43 @@
+package ipld
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// TestUCB1AxisPickerConcurrent drives Pick and Observe from many goroutines at
+// once against a shared set of roots. It exists to be run with -race: Pick
+// used to score the live *rootStats after releasing p.mu, racing against
+// Observe mutating those same fields on another goroutine.
+func TestUCB1AxisPickerConcurrent(t *testing.T) {
+	picker := NewUCB1AxisPicker(16)
+
+	roots := make([]cid.Cid, 4)
+	for i := range roots {
+		hash := bytes.Repeat([]byte{byte(i)}, nmtHashSize)
+		roots[i] = MustCidFromNamespacedSha256(hash)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		i := i
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			picker.Pick(roots[i%2], roots[2+i%2])
+		}()
+		go func() {
+			defer wg.Done()
+			axis := AxisRow
+			if i%2 == 1 {
+				axis = AxisCol
+			}
+			picker.Observe(axis, roots[i%len(roots)], time.Millisecond, nil)
+		}()
+	}
+	wg.Wait()
+}
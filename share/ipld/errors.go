@@ -0,0 +1,36 @@
+package ipld
+
+import (
+	"errors"
+	"fmt"
+
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+var (
+	// ErrNodeNotFound is returned when a block could not be retrieved from
+	// the network or a local store. It wraps the underlying
+	// go-ipld-format.ErrNotFound, so callers can tell "namespace absent"
+	// apart from other failures with errors.Is(err, ErrNodeNotFound)
+	// instead of string matching, while errors.As(err, &notFound) still
+	// reaches the concrete *ipld.ErrNotFound underneath.
+	ErrNodeNotFound = errors.New("ipld: node not found")
+
+	// ErrInvalidNodeSize is returned by decodeBlock when a block's raw data
+	// does not match the inner- or leaf-node size registered for its codec.
+	ErrInvalidNodeSize = errors.New("ipld: wrong sized data carried in block")
+
+	// ErrInvalidPath is returned by Resolve/ResolveLink when given a path an
+	// NMT node cannot follow.
+	ErrInvalidPath = errors.New("ipld: invalid path")
+)
+
+// wrapNotFound wraps err as ErrNodeNotFound if it is an ipld.ErrNotFound,
+// preserving err in its error chain.
+func wrapNotFound(err error) error {
+	var errNotFound *ipld.ErrNotFound
+	if errors.As(err, &errNotFound) {
+		return fmt.Errorf("%w: %w", ErrNodeNotFound, errNotFound)
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package ipld
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// nmtHasherRegistration describes how to build and parse namespaced-hash CIDs
+// for a given codec: the multihash code it is encoded under, the namespace
+// and digest sizes it uses, and the hash.Hash implementation that produces it.
+type nmtHasherRegistration struct {
+	mhCode   uint64
+	nsSize   int
+	hashSize int
+	newHash  func() hash.Hash
+}
+
+var (
+	nmtHasherRegistryMu sync.RWMutex
+	nmtHasherRegistry   = make(map[uint64]nmtHasherRegistration)
+)
+
+// RegisterNMTHasher registers a namespaced-hash codec so CidFromNamespacedHash,
+// NamespacedHashFromCID and decodeBlock can look up its multihash code, sizes
+// and hasher instead of assuming sha256Namespace8Flagged. It is meant to be
+// called from an init() function; registering the same codec twice panics.
+func RegisterNMTHasher(codec, mhCode uint64, nsSize, hashSize int, newHash func() hash.Hash) {
+	nmtHasherRegistryMu.Lock()
+	defer nmtHasherRegistryMu.Unlock()
+
+	if _, ok := nmtHasherRegistry[codec]; ok {
+		panic(fmt.Sprintf("ipld: nmt hasher for codec %#x already registered", codec))
+	}
+
+	nmtHasherRegistry[codec] = nmtHasherRegistration{
+		mhCode:   mhCode,
+		nsSize:   nsSize,
+		hashSize: hashSize,
+		newHash:  newHash,
+	}
+}
+
+// lookupNMTHasher returns the registration for codec, or an error if nothing
+// has registered it via RegisterNMTHasher.
+func lookupNMTHasher(codec uint64) (nmtHasherRegistration, error) {
+	nmtHasherRegistryMu.RLock()
+	defer nmtHasherRegistryMu.RUnlock()
+
+	reg, ok := nmtHasherRegistry[codec]
+	if !ok {
+		return nmtHasherRegistration{}, fmt.Errorf("ipld: no nmt hasher registered for codec %#x", codec)
+	}
+	return reg, nil
+}
+
+func init() {
+	RegisterNMTHasher(nmtCodec, sha256Namespace8Flagged, NamespaceSize, nmtHashSize, sha256.New)
+}
@@ -0,0 +1,359 @@
+package ipld
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"runtime"
+	"sync"
+)
+
+// ParallelNMTHasher computes an NMT root the same way the sequential `nmt`
+// tree hasher does, except that once two siblings at a level are both known,
+// their parent hash is computed on a bounded worker pool instead of in-line.
+// This overlaps the otherwise strictly-sequential push model across the many
+// independent row/column subtrees touched while reconstructing a full square.
+//
+// Leaves must be pushed in left-to-right order via Push. Each level tracks
+// its values by position (not by arrival order), so two sibling pairs at the
+// same level can complete out of order on different workers without ever
+// mixing up which value is the left child and which is the right: a pair is
+// combined only once both of its positions are filled, using the even index
+// as left and the odd index as right regardless of which one finished its
+// hash first. Root blocks until every dispatched parent hash has completed,
+// then folds the remaining right spine from the lowest level up, which
+// reproduces the `nmt` library's split of a leaf count that is not a power
+// of two at its largest power of two (no padding leaves are added).
+type ParallelNMTHasher struct {
+	mu      sync.Mutex
+	levels  []hashLevel // levels[l].values[i] is the hash at (level l, position i)
+	leaves  int
+	newHash func() hash.Hash
+
+	sem chan struct{}
+	wg  sync.WaitGroup
+}
+
+// hashLevel holds one level's values by position, plus which sibling pairs
+// have already been dispatched to the worker pool so a pair is never
+// combined twice.
+type hashLevel struct {
+	values []([]byte)
+	paired []bool
+}
+
+// NewParallelNMTHasher constructs a ParallelNMTHasher whose parent-hash
+// computations are bounded by runtime.GOMAXPROCS(0) concurrent workers, so
+// reconstructing a full square cannot oversubscribe the machine. newHash
+// builds the digest for each inner node; pass the same hasher the tree's
+// leaves were hashed with, e.g. the one RegisterNMTHasher registered for the
+// tree's codec.
+func NewParallelNMTHasher(newHash func() hash.Hash) *ParallelNMTHasher {
+	return &ParallelNMTHasher{
+		newHash: newHash,
+		sem:     make(chan struct{}, runtime.GOMAXPROCS(0)),
+	}
+}
+
+// Push appends the next leaf's namespaced hash, in left-to-right order.
+func (p *ParallelNMTHasher) Push(leafHash []byte) {
+	p.mu.Lock()
+	idx := p.leaves
+	p.leaves++
+	p.mu.Unlock()
+
+	p.setValue(0, idx, leafHash)
+}
+
+// setValue records hash as the value at (level, idx), growing that level's
+// bookkeeping as needed, then dispatches the sibling pair it may complete.
+func (p *ParallelNMTHasher) setValue(level, idx int, hash []byte) {
+	p.mu.Lock()
+	for len(p.levels) <= level {
+		p.levels = append(p.levels, hashLevel{})
+	}
+	lv := &p.levels[level]
+	for len(lv.values) <= idx {
+		lv.values = append(lv.values, nil)
+	}
+	lv.values[idx] = hash
+	p.mu.Unlock()
+
+	p.maybeDispatch(level, idx)
+}
+
+// maybeDispatch checks whether idx's sibling is also known and, if so and
+// the pair hasn't been dispatched yet, hashes it on the worker pool. Siblings
+// are always combined as (2k, 2k+1) regardless of which one's setValue call
+// observes the pair is complete, so completion order cannot swap left/right.
+func (p *ParallelNMTHasher) maybeDispatch(level, idx int) {
+	pairIdx := idx / 2
+	leftIdx, rightIdx := 2*pairIdx, 2*pairIdx+1
+
+	p.mu.Lock()
+	lv := &p.levels[level]
+	if rightIdx >= len(lv.values) || lv.values[leftIdx] == nil || lv.values[rightIdx] == nil {
+		p.mu.Unlock()
+		return // sibling not ready yet; whichever finishes second dispatches the pair
+	}
+	for len(lv.paired) <= pairIdx {
+		lv.paired = append(lv.paired, false)
+	}
+	if lv.paired[pairIdx] {
+		p.mu.Unlock()
+		return // the other sibling's setValue call already dispatched this pair
+	}
+	lv.paired[pairIdx] = true
+	left, right := lv.values[leftIdx], lv.values[rightIdx]
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		p.sem <- struct{}{}
+		parent := hashNMTNode(p.newHash, left, right)
+		<-p.sem
+
+		p.setValue(level+1, pairIdx, parent)
+	}()
+}
+
+// Root waits for all in-flight parent hashes to complete and returns the
+// namespaced root hash of every leaf pushed so far.
+func (p *ParallelNMTHasher) Root() []byte {
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.leaves == 0 {
+		return emptyNMTHash(p.newHash)
+	}
+
+	// Each level has at most one leftover value that never found a sibling
+	// (its index has no pair because the level's length is odd). Folding
+	// those leftovers from the lowest level up combines the smaller,
+	// rightmost subtrees first and puts each larger, leftmost one on the
+	// left of the result, which is the spine a non-power-of-two leaf count
+	// leaves behind.
+	var acc []byte
+	for level := 0; level < len(p.levels); level++ {
+		values := p.levels[level].values
+		if len(values)%2 == 0 {
+			continue
+		}
+		leftover := values[len(values)-1]
+		if acc == nil {
+			acc = leftover
+			continue
+		}
+		acc = hashNMTNode(p.newHash, leftover, acc)
+	}
+	return acc
+}
+
+// Domain-separation prefixes for leaf vs. inner-node digests, matching the
+// `nmt` library's tree hasher: without these, an inner node's
+// sha256(left||right) could collide with some other level's concatenation,
+// since nothing about the bytes being hashed says which kind of node they
+// came from.
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// hashNMTNode computes the namespaced hash of an inner node from its two
+// children's namespaced hashes, honoring NMTIgnoreMaxNamespace for the
+// namespace range the same way the sequential hasher does. newHash must be
+// the same hasher the tree's leaves were hashed with (the registry's default
+// is sha256.New, but this is never hardcoded here so a codec registered with
+// a different hash, e.g. via RegisterNMTHasher, is actually honored rather
+// than silently re-hashed with sha256). The digest itself must match what
+// `nmt.New()` produces for the same children, since this is the root
+// celestia-app and the rest of the chain verify against.
+func hashNMTNode(newHash func() hash.Hash, left, right []byte) []byte {
+	min, max := foldNamespaceRange(left, right)
+
+	h := newHash()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+
+	out := make([]byte, 0, 2*NamespaceSize+h.Size())
+	out = append(out, min...)
+	out = append(out, max...)
+	return h.Sum(out)
+}
+
+// hashNMTLeaf computes the namespaced hash of a single leaf the way the `nmt`
+// library does: the digest is domain-separated from inner-node digests by
+// leafPrefix, and a leaf's own namespace is both its min and max. newHash
+// must match the hasher the rest of the tree is built with.
+func hashNMTLeaf(newHash func() hash.Hash, namespace, data []byte) []byte {
+	h := newHash()
+	h.Write([]byte{leafPrefix})
+	h.Write(namespace)
+	h.Write(data)
+
+	out := make([]byte, 0, 2*NamespaceSize+h.Size())
+	out = append(out, namespace...)
+	out = append(out, namespace...)
+	return h.Sum(out)
+}
+
+// foldNamespaceRange computes the (min, max) namespace range of a parent node
+// from its children's namespaced hashes. max is the right child's max unless
+// NMTIgnoreMaxNamespace is set and the right child holds only the MAX_NID
+// sentinel (its min is MAX_NID), in which case the sentinel is treated as
+// absent and the left child's max is propagated instead.
+func foldNamespaceRange(left, right []byte) (min, max []byte) {
+	min = MinNamespace(left)
+	max = MaxNamespace(right)
+
+	if NMTIgnoreMaxNamespace {
+		if bytes.Equal(MinNamespace(right), maxNamespaceID()) {
+			max = MaxNamespace(left)
+		}
+	}
+	return min, max
+}
+
+// maxNamespaceID returns the MAX_NID sentinel namespace ID: all bits set.
+func maxNamespaceID() []byte {
+	id := make([]byte, NamespaceSize)
+	for i := range id {
+		id[i] = 0xFF
+	}
+	return id
+}
+
+// emptyNMTHash is the namespaced hash of an NMT with no leaves, under newHash.
+func emptyNMTHash(newHash func() hash.Hash) []byte {
+	h := newHash().Sum(nil)
+	out := make([]byte, 0, 2*NamespaceSize+len(h))
+	out = append(out, make([]byte, NamespaceSize)...)
+	out = append(out, make([]byte, NamespaceSize)...)
+	return append(out, h...)
+}
+
+// AxisRootOption configures how a row or column root is computed from its
+// leaves' namespaced hashes.
+type AxisRootOption func(*axisRootConfig)
+
+type axisRootConfig struct {
+	parallel bool
+	codec    uint64
+}
+
+// WithParallelHasher opts a row/column root computation into
+// ParallelNMTHasher instead of the sequential push-one-leaf-at-a-time model.
+// Extended-square root computation should pass this when reconstructing a
+// full square, where the number of independent row/column trees makes the
+// parallel hasher's synchronization overhead worth paying.
+func WithParallelHasher() AxisRootOption {
+	return func(c *axisRootConfig) { c.parallel = true }
+}
+
+// WithCodec selects which registered NMT hasher (see RegisterNMTHasher)
+// computes inner-node digests. Defaults to nmtCodec, the sha256/namespace-8
+// registration installed by this package's own init(); pass the codec a leaf
+// hash's CID was built under if it differs.
+func WithCodec(codec uint64) AxisRootOption {
+	return func(c *axisRootConfig) { c.codec = codec }
+}
+
+// ComputeAxisRoot computes the namespaced root hash of an ordered sequence of
+// a row's or column's leaf namespaced hashes, hashing inner nodes with the
+// hasher WithCodec selects (nmtCodec's sha256 registration by default). By
+// default hashes are folded in-line on the calling goroutine with no
+// dispatching at all; WithParallelHasher switches to ParallelNMTHasher,
+// bounded by runtime.GOMAXPROCS(0), so independent subtrees can be hashed
+// concurrently.
+func ComputeAxisRoot(leafHashes [][]byte, opts ...AxisRootOption) []byte {
+	cfg := &axisRootConfig{codec: nmtCodec}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	reg, err := lookupNMTHasher(cfg.codec)
+	if err != nil {
+		panic(fmt.Sprintf("ipld: %s", err))
+	}
+
+	if !cfg.parallel {
+		return sequentialNMTRoot(reg.newHash, leafHashes)
+	}
+
+	hasher := NewParallelNMTHasher(reg.newHash)
+	for _, h := range leafHashes {
+		hasher.Push(h)
+	}
+	return hasher.Root()
+}
+
+// ComputeExtendedSquareRoots computes the row and column roots of a full
+// (already erasure-extended) square of leaf namespaced hashes, given as
+// square[row][col]. This snapshot does not vendor rsmt2d.ExtendedDataSquare
+// itself, so this is the actual extended-square root computation entry point
+// in this package: a caller with a real ExtendedDataSquare should feed its
+// row/column leaf hashes through here rather than calling ComputeAxisRoot
+// once per axis by hand, so opts (e.g. WithParallelHasher) apply uniformly
+// across every row and column.
+func ComputeExtendedSquareRoots(square [][][]byte, opts ...AxisRootOption) (rowRoots, colRoots [][]byte) {
+	width := len(square)
+
+	rowRoots = make([][]byte, width)
+	for row := range square {
+		rowRoots[row] = ComputeAxisRoot(square[row], opts...)
+	}
+
+	colRoots = make([][]byte, width)
+	for col := 0; col < width; col++ {
+		column := make([][]byte, width)
+		for row := 0; row < width; row++ {
+			column[row] = square[row][col]
+		}
+		colRoots[col] = ComputeAxisRoot(column, opts...)
+	}
+	return rowRoots, colRoots
+}
+
+// sequentialNMTRoot folds leafHashes into a root using the same left/right
+// combining rule as ParallelNMTHasher, but synchronously on the calling
+// goroutine with no worker dispatch, since a single-threaded fold can never
+// race on completion order the way concurrent dispatch can.
+func sequentialNMTRoot(newHash func() hash.Hash, leafHashes [][]byte) []byte {
+	if len(leafHashes) == 0 {
+		return emptyNMTHash(newHash)
+	}
+
+	var spine [][]byte // spine[level] holds the pending value at that level, if any
+	for _, leaf := range leafHashes {
+		cur := leaf
+		for level := 0; ; level++ {
+			for len(spine) <= level {
+				spine = append(spine, nil)
+			}
+			if spine[level] == nil {
+				spine[level] = cur
+				break
+			}
+			cur = hashNMTNode(newHash, spine[level], cur)
+			spine[level] = nil
+		}
+	}
+
+	var acc []byte
+	for level := 0; level < len(spine); level++ {
+		if spine[level] == nil {
+			continue
+		}
+		if acc == nil {
+			acc = spine[level]
+			continue
+		}
+		acc = hashNMTNode(newHash, spine[level], acc)
+	}
+	return acc
+}
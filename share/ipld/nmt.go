@@ -1,11 +1,12 @@
 package ipld
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"errors"
 	"fmt"
-	"math/rand"
+	"time"
 
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-blockservice"
@@ -51,31 +52,87 @@ const (
 	cidPrefixSize = 4
 )
 
+// NMTIgnoreMaxNamespace is mirrored to the `nmt` library's IgnoreMaxNamespace
+// option. When enabled, an inner node's namespace range treats the sentinel
+// MAX namespace ID as absent unless both children carry it, which shortens
+// proofs for namespaces bordering the upper bound. Every tree and proof built
+// in this package must agree on this setting, so it lives here as the single
+// source of truth rather than being hard-coded per call site. It is a var,
+// not a const, because this is a consensus-relevant wire format change: a
+// node talking to peers still on the non-folded format needs a way to turn
+// it back off.
+var NMTIgnoreMaxNamespace = true
+
+// NewNMT constructs an NMT tree using the nmtCodec registration's hasher (see
+// RegisterNMTHasher), the system-wide namespace size, and the
+// IgnoreMaxNamespace setting, so that every tree and proof built by this
+// package is produced and verified under the same rules.
+func NewNMT() *nmt.NMT {
+	reg, err := lookupNMTHasher(nmtCodec)
+	if err != nil {
+		panic(fmt.Sprintf("ipld: %s", err))
+	}
+
+	return nmt.New(
+		reg.newHash(),
+		nmt.NamespaceIDSize(NamespaceSize),
+		nmt.IgnoreMaxNamespace(NMTIgnoreMaxNamespace),
+	)
+}
+
 func GetNode(ctx context.Context, bGetter blockservice.BlockGetter, root cid.Cid) (ipld.Node, error) {
-	block, err := bGetter.GetBlock(ctx, root)
+	release, err := Workers.Acquire(ctx)
 	if err != nil {
-		var errNotFound *ipld.ErrNotFound
-		if errors.As(err, &errNotFound) {
-			return nil, errNotFound
-		}
 		return nil, err
 	}
+	defer release()
+
+	block, err := bGetter.GetBlock(ctx, root)
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
 
 	return decodeBlock(block)
 }
 
 func decodeBlock(block blocks.Block) (ipld.Node, error) {
-	innerNodeSize, leafNodeSize := (nmtHashSize)*2, NamespaceSize+consts.ShareSize
+	reg, err := lookupNMTHasher(block.Cid().Prefix().Codec)
+	if err != nil {
+		return nil, err
+	}
+
+	innerNodeSize, leafNodeSize := reg.hashSize*2, reg.nsSize+appconsts.ShareSize
 	switch len(block.RawData()) {
 	default:
-		return nil, fmt.Errorf("ipld: wrong sized data carried in block")
+		return nil, ErrInvalidNodeSize
 	case innerNodeSize:
-		return &nmtNode{block}, nil
+		n := &nmtNode{block}
+		if err := verifyInnerNamespaceRange(*n); err != nil {
+			return nil, err
+		}
+		return n, nil
 	case leafNodeSize:
 		return &nmtLeafNode{nmtNode{block}}, nil
 	}
 }
 
+// verifyInnerNamespaceRange recomputes an inner node's (min, max) namespace
+// range from its children under the current NMTIgnoreMaxNamespace setting
+// and checks it against the range actually encoded in the node's own
+// namespaced hash, so a node built or relayed under a different setting is
+// rejected on the read path rather than silently accepted.
+func verifyInnerNamespaceRange(n nmtNode) error {
+	wantMin, wantMax := foldNamespaceRange(n.left(), n.right())
+	gotMin, gotMax := n.Namespace()
+	if !bytes.Equal(wantMin, gotMin) || !bytes.Equal(wantMax, gotMax) {
+		return fmt.Errorf(
+			"ipld: inner node namespace range does not match NMTIgnoreMaxNamespace=%v: got (%x,%x), want (%x,%x)",
+			NMTIgnoreMaxNamespace, gotMin, gotMax, wantMin, wantMax,
+		)
+	}
+	return nil
+}
+
 var _ ipld.Node = (*nmtNode)(nil)
 var _ ipld.Node = (*nmtLeafNode)(nil)
 
@@ -92,21 +149,22 @@ func newNMTNode(id cid.Cid, data []byte) nmtNode {
 }
 
 func (n nmtNode) Resolve(path []string) (interface{}, []string, error) {
+	codec := n.Cid().Prefix().Codec
 	switch path[0] {
 	case "0":
-		left, err := CidFromNamespacedSha256(n.left())
+		left, err := CidFromNamespacedHash(codec, n.left())
 		if err != nil {
 			return nil, nil, err
 		}
 		return &ipld.Link{Cid: left}, path[1:], nil
 	case "1":
-		right, err := CidFromNamespacedSha256(n.right())
+		right, err := CidFromNamespacedHash(codec, n.right())
 		if err != nil {
 			return nil, nil, err
 		}
 		return &ipld.Link{Cid: right}, path[1:], nil
 	default:
-		return nil, nil, errors.New("invalid path for inner node")
+		return nil, nil, fmt.Errorf("%w for inner node: %q", ErrInvalidPath, path[0])
 	}
 }
 
@@ -142,8 +200,9 @@ func (n nmtNode) Copy() ipld.Node {
 }
 
 func (n nmtNode) Links() []*ipld.Link {
-	leftCid := MustCidFromNamespacedSha256(n.left())
-	rightCid := MustCidFromNamespacedSha256(n.right())
+	codec := n.Cid().Prefix().Codec
+	leftCid := MustCidFromNamespacedHash(codec, n.left())
+	rightCid := MustCidFromNamespacedHash(codec, n.right())
 
 	return []*ipld.Link{{Cid: leftCid}, {Cid: rightCid}}
 }
@@ -157,11 +216,45 @@ func (n nmtNode) Size() (uint64, error) {
 }
 
 func (n nmtNode) left() []byte {
-	return n.RawData()[:nmtHashSize]
+	return n.RawData()[:n.childHashSize()]
 }
 
 func (n nmtNode) right() []byte {
-	return n.RawData()[nmtHashSize:]
+	return n.RawData()[n.childHashSize():]
+}
+
+// childHashSize returns the namespaced hash size used by this node's codec,
+// falling back to the default sha256 registration if, for whatever reason,
+// the node's own codec was never registered (decodeBlock already validated
+// it when the node was constructed, so this should not happen in practice).
+func (n nmtNode) childHashSize() int {
+	reg, err := lookupNMTHasher(n.Cid().Prefix().Codec)
+	if err != nil {
+		return nmtHashSize
+	}
+	return reg.hashSize
+}
+
+// Namespace returns the (min, max) namespace range covered by this node, as
+// encoded in its own namespaced hash. When the tree was built with
+// NMTIgnoreMaxNamespace, max is the left child's max whenever the right child
+// holds only the MAX_NID sentinel, so MAX_NID only appears as max if both
+// children carry it or the right child also holds other namespaces.
+func (n nmtNode) Namespace() (min, max []byte) {
+	h := NamespacedSha256FromCID(n.Cid())
+	return MinNamespace(h), MaxNamespace(h)
+}
+
+// MinNamespace returns the minimum namespace ID encoded in a namespaced hash
+// produced by an NMT (inner or leaf node).
+func MinNamespace(namespacedHash []byte) []byte {
+	return namespacedHash[:NamespaceSize]
+}
+
+// MaxNamespace returns the maximum namespace ID encoded in a namespaced hash
+// produced by an NMT (inner or leaf node).
+func MaxNamespace(namespacedHash []byte) []byte {
+	return namespacedHash[NamespaceSize : 2*NamespaceSize]
 }
 
 type nmtLeafNode struct {
@@ -173,7 +266,7 @@ func newNMTLeafNode(id cid.Cid, data []byte) nmtLeafNode {
 }
 
 func (l nmtLeafNode) Resolve(path []string) (interface{}, []string, error) {
-	return nil, nil, errors.New("invalid path for leaf node")
+	return nil, nil, fmt.Errorf("%w for leaf node: %q", ErrInvalidPath, path)
 }
 
 func (l nmtLeafNode) Tree(_path string, _depth int) []string {
@@ -184,43 +277,155 @@ func (l nmtLeafNode) Links() []*ipld.Link {
 	return nil
 }
 
-// CidFromNamespacedSha256 uses a hash from an nmt tree to create a CID
-func CidFromNamespacedSha256(namespacedHash []byte) (cid.Cid, error) {
-	if got, want := len(namespacedHash), nmtHashSize; got != want {
+// CidFromNamespacedHash uses a namespaced hash produced by the NMT registered
+// under codec to build a CID, looking up the expected hash size and multihash
+// code from the registry instead of assuming sha256Namespace8Flagged.
+func CidFromNamespacedHash(codec uint64, namespacedHash []byte) (cid.Cid, error) {
+	reg, err := lookupNMTHasher(codec)
+	if err != nil {
+		return cid.Cid{}, err
+	}
+	if got, want := len(namespacedHash), reg.hashSize; got != want {
 		return cid.Cid{}, fmt.Errorf("invalid namespaced hash length, got: %v, want: %v", got, want)
 	}
-	buf, err := mh.Encode(namespacedHash, sha256Namespace8Flagged)
+	buf, err := mh.Encode(namespacedHash, reg.mhCode)
 	if err != nil {
 		return cid.Undef, err
 	}
-	return cid.NewCidV1(nmtCodec, buf), nil
+	return cid.NewCidV1(codec, buf), nil
 }
 
-// MustCidFromNamespacedSha256 is a wrapper around cidFromNamespacedSha256 that panics
-// in case of an error. Use with care and only in places where no error should occur.
-func MustCidFromNamespacedSha256(hash []byte) cid.Cid {
-	cidFromHash, err := CidFromNamespacedSha256(hash)
+// MustCidFromNamespacedHash is a wrapper around CidFromNamespacedHash that
+// panics in case of an error. Use with care and only in places where no error
+// should occur.
+func MustCidFromNamespacedHash(codec uint64, namespacedHash []byte) cid.Cid {
+	cidFromHash, err := CidFromNamespacedHash(codec, namespacedHash)
 	if err != nil {
-		panic(
-			fmt.Sprintf("malformed hash: %s, codec: %v",
-				err,
-				mh.Codes[sha256Namespace8Flagged]),
-		)
+		panic(fmt.Sprintf("malformed hash: %s, codec: %#x", err, codec))
 	}
 	return cidFromHash
 }
 
+// CidFromNamespacedSha256 uses a hash from an nmt tree to create a CID under
+// the default sha256 / namespace-8 codec. Kept for callers that have not
+// migrated to the registry-based CidFromNamespacedHash.
+func CidFromNamespacedSha256(namespacedHash []byte) (cid.Cid, error) {
+	return CidFromNamespacedHash(nmtCodec, namespacedHash)
+}
+
+// MustCidFromNamespacedSha256 is a wrapper around CidFromNamespacedSha256 that
+// panics in case of an error. Use with care and only in places where no error
+// should occur.
+func MustCidFromNamespacedSha256(namespacedHash []byte) cid.Cid {
+	return MustCidFromNamespacedHash(nmtCodec, namespacedHash)
+}
+
+// defaultAxisPicker is the AxisPicker Translate consults. It defaults to an
+// unweighted coin flip to preserve Translate's historical behavior; override
+// it with SetDefaultAxisPicker, or pass WithAxisPicker to TranslateWithOptions
+// for a call-scoped override.
+var defaultAxisPicker AxisPicker = NewRandomAxisPicker()
+
+// SetDefaultAxisPicker overrides the AxisPicker Translate consults for axis
+// selection. Not safe to call concurrently with Translate.
+func SetDefaultAxisPicker(p AxisPicker) {
+	defaultAxisPicker = p
+}
+
+// DefaultAxisPicker returns the AxisPicker Translate currently consults, so
+// fetch code can report outcomes back to it via Observe.
+func DefaultAxisPicker() AxisPicker {
+	return defaultAxisPicker
+}
+
+// TranslateOption configures TranslateWithOptions's axis selection.
+type TranslateOption func(*translateConfig)
+
+type translateConfig struct {
+	picker AxisPicker
+}
+
+// WithAxisPicker overrides the AxisPicker used for a single
+// TranslateWithOptions call. share.Getter constructors with skewed bitswap
+// peer sets should accept this option and thread it through here instead of
+// relying on the random default, so they stop wasting round trips on the
+// slow axis.
+func WithAxisPicker(p AxisPicker) TranslateOption {
+	return func(c *translateConfig) { c.picker = p }
+}
+
 // Translate transforms square coordinates into IPLD NMT tree path to a leaf node.
-// It also adds randomization to evenly spread fetching from Rows and Columns.
+// It consults the default AxisPicker (a coin flip, unless overridden via
+// SetDefaultAxisPicker) to pick whether the row or column root is resolved.
 func Translate(dah *da.DataAvailabilityHeader, row, col int) (cid.Cid, int) {
-	if rand.Intn(2) == 0 { //nolint:gosec
-		return MustCidFromNamespacedSha256(dah.ColumnRoots[col]), row
+	return TranslateWithOptions(dah, row, col)
+}
+
+// TranslateWithOptions is Translate with a call-scoped AxisPicker override
+// via WithAxisPicker; it otherwise falls back to the default AxisPicker.
+func TranslateWithOptions(dah *da.DataAvailabilityHeader, row, col int, opts ...TranslateOption) (cid.Cid, int) {
+	cfg := &translateConfig{picker: defaultAxisPicker}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	_, root, idx := pickAxis(dah, row, col, cfg.picker)
+	return root, idx
+}
+
+// pickAxis resolves both candidate roots for (row, col) and asks picker
+// which to use. It is factored out of TranslateWithOptions so GetLeaf can
+// also learn which axis was chosen, to Observe the fetch outcome against it.
+func pickAxis(dah *da.DataAvailabilityHeader, row, col int, picker AxisPicker) (axis Axis, root cid.Cid, otherIndex int) {
+	rowRoot := MustCidFromNamespacedSha256(dah.RowsRoots[row])
+	colRoot := MustCidFromNamespacedSha256(dah.ColumnRoots[col])
+
+	if picker.Pick(rowRoot, colRoot) == AxisCol {
+		return AxisCol, colRoot, row
+	}
+	return AxisRow, rowRoot, col
+}
+
+// GetLeaf resolves the axis root for (row, col) via TranslateWithOptions and
+// fetches it with GetNode, reporting the fetch's latency and outcome back to
+// the AxisPicker that chose it so future picks can take it into account.
+// This is the one real caller of AxisPicker.Observe in this snapshot;
+// share.Getter's own fetch loop should call Observe the same way once
+// share/getter.go exists in this tree.
+func GetLeaf(
+	ctx context.Context, bGetter blockservice.BlockGetter, dah *da.DataAvailabilityHeader, row, col int,
+	opts ...TranslateOption,
+) (ipld.Node, error) {
+	cfg := &translateConfig{picker: defaultAxisPicker}
+	for _, opt := range opts {
+		opt(cfg)
 	}
 
-	return MustCidFromNamespacedSha256(dah.RowsRoots[row]), col
+	axis, root, _ := pickAxis(dah, row, col, cfg.picker)
+
+	start := time.Now()
+	node, err := GetNode(ctx, bGetter, root)
+	cfg.picker.Observe(axis, root, time.Since(start), err)
+
+	return node, err
+}
+
+// NamespacedHashFromCID derives the namespaced hash from the given CID: the
+// last hashSize bytes of its multihash, as registered for the CID's codec. A
+// CID whose codec is not registered is sliced after the cidPrefixSize prefix
+// of the default sha256Namespace8Flagged encoding.
+func NamespacedHashFromCID(cid cid.Cid) []byte {
+	h := cid.Hash()
+	reg, err := lookupNMTHasher(cid.Prefix().Codec)
+	if err != nil {
+		return h[cidPrefixSize:]
+	}
+	return h[len(h)-reg.hashSize:]
 }
 
 // NamespacedSha256FromCID derives the Namespaced hash from the given CID.
+//
+// Deprecated: use NamespacedHashFromCID, which is not tied to sha256.
 func NamespacedSha256FromCID(cid cid.Cid) []byte {
-	return cid.Hash()[cidPrefixSize:]
-}
\ No newline at end of file
+	return NamespacedHashFromCID(cid)
+}
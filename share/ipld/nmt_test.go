@@ -0,0 +1,61 @@
+package ipld
+
+import (
+	"bytes"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+)
+
+// TestDecodeBlockVerifiesInnerNamespaceRange round-trips an inner node
+// through decodeBlock: a node whose own encoded namespace range matches what
+// foldNamespaceRange derives from its children must be accepted, and one
+// whose range has been tampered with must be rejected by
+// verifyInnerNamespaceRange rather than silently passed through.
+func TestDecodeBlockVerifiesInnerNamespaceRange(t *testing.T) {
+	reg, err := lookupNMTHasher(nmtCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	namespace := bytes.Repeat([]byte{0x01}, NamespaceSize)
+	left := hashNMTLeaf(reg.newHash, namespace, []byte("left"))
+	right := hashNMTLeaf(reg.newHash, namespace, []byte("right"))
+	parent := hashNMTNode(reg.newHash, left, right)
+
+	rawData := make([]byte, 0, len(left)+len(right))
+	rawData = append(rawData, left...)
+	rawData = append(rawData, right...)
+
+	goodCid, err := CidFromNamespacedHash(nmtCodec, parent)
+	if err != nil {
+		t.Fatalf("CidFromNamespacedHash: %s", err)
+	}
+	goodBlock, err := blocks.NewBlockWithCid(rawData, goodCid)
+	if err != nil {
+		t.Fatalf("NewBlockWithCid: %s", err)
+	}
+
+	node, err := decodeBlock(goodBlock)
+	if err != nil {
+		t.Fatalf("decodeBlock rejected a correctly constructed inner node: %s", err)
+	}
+	if _, ok := node.(*nmtNode); !ok {
+		t.Fatalf("decodeBlock returned %T, want *nmtNode", node)
+	}
+
+	corruptParent := append([]byte{}, parent...)
+	corruptParent[NamespaceSize] ^= 0xFF // flip a byte inside the encoded max namespace
+	badCid, err := CidFromNamespacedHash(nmtCodec, corruptParent)
+	if err != nil {
+		t.Fatalf("CidFromNamespacedHash: %s", err)
+	}
+	badBlock, err := blocks.NewBlockWithCid(rawData, badCid)
+	if err != nil {
+		t.Fatalf("NewBlockWithCid: %s", err)
+	}
+
+	if _, err := decodeBlock(badBlock); err == nil {
+		t.Fatal("decodeBlock accepted an inner node whose namespace range does not match its children")
+	}
+}
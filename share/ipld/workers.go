@@ -0,0 +1,84 @@
+package ipld
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// NumConcurrentSquares is the number of extended squares expected to be
+	// reconstructed/fetched from at once. Nothing in this package reads it;
+	// Workers is bounded by NumWorkersLimit alone.
+	NumConcurrentSquares = 8
+
+	// NumWorkersLimit bounds how many IPLD node fetches may be in flight at
+	// once across all squares sharing Workers, so catching up on N blocks in
+	// parallel cannot spawn N*128*64 unbounded goroutines. It is deliberately
+	// sized as a small multiple of GOMAXPROCS rather than scaled up by
+	// NumConcurrentSquares*MaxSquareSize*MaxSquareSize: a limit anywhere near
+	// that worst case would let Acquire sail through without ever blocking,
+	// which is no bound at all.
+	NumWorkersLimit = 4 * runtime.GOMAXPROCS(0)
+)
+
+// Workers is the package-level semaphore-guarded pool every IPLD-driven
+// share fetch routes through. GetNode, and any recursive traversal helper
+// that walks an NMT tree, must acquire from it before touching the network
+// or a local blockstore.
+var Workers = newWorkerPool(NumWorkersLimit)
+
+type workerPool struct {
+	sem chan struct{}
+}
+
+func newWorkerPool(limit int) *workerPool {
+	return &workerPool{sem: make(chan struct{}, limit)}
+}
+
+// ConfigureWorkers rebuilds Workers with the given limit and updates
+// NumWorkersLimit to match. Reassigning NumWorkersLimit by itself has no
+// effect, since Workers is already constructed by the time package init
+// finishes; this is the actual way to change the bound at runtime. It is not
+// safe to call concurrently with in-flight Acquire/release pairs on the pool
+// being replaced — call it during setup, before fetches start.
+func ConfigureWorkers(limit int) {
+	NumWorkersLimit = limit
+	Workers = newWorkerPool(limit)
+}
+
+// Acquire blocks until a worker slot is free or ctx is done. On success, the
+// caller must call release exactly once, however it returns.
+func (p *workerPool) Acquire(ctx context.Context) (release func(), err error) {
+	workersQueueDepth.Inc()
+	defer workersQueueDepth.Dec()
+
+	select {
+	case p.sem <- struct{}{}:
+		return func() { <-p.sem }, nil
+	case <-ctx.Done():
+		workersRejected.Inc()
+		return func() {}, ctx.Err()
+	}
+}
+
+var (
+	workersQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ipld",
+		Subsystem: "workers",
+		Name:      "queue_depth",
+		Help:      "Number of IPLD node fetches currently waiting for a free worker slot.",
+	})
+
+	workersRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ipld",
+		Subsystem: "workers",
+		Name:      "rejected_total",
+		Help:      "Number of IPLD node fetches that gave up waiting for a worker slot because their context ended first.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(workersQueueDepth, workersRejected)
+}